@@ -0,0 +1,181 @@
+package debounce
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxOutputSize is the per-run cap on cached stdout/stderr, beyond
+// which output is simply not cached (the command still runs, and its
+// output still streams through, but nothing is kept for replay).
+const defaultMaxOutputSize = 1 << 20 // 1MiB
+
+// defaultCacheBudget is the total size, across all markers' cached
+// output, that Cleanup tries to keep the cache directory under.
+const defaultCacheBudget = 100 << 20 // 100MiB
+
+// outputPaths returns the on-disk locations of a hash's cached
+// stdout/stderr, if the Storage backend is actually disk-backed.
+// Output capture only works for such backends: it streams through a real
+// *os.File alongside the live stdout/stderr, which an abstract Storage
+// (e.g. one used in tests) has no equivalent for.
+func outputPaths(storage Storage, hash string) (out, errOut string, ok bool) {
+	pather, supported := storage.(diskPather)
+	if !supported {
+		return "", "", false
+	}
+	out, ok1 := pather.DiskPath(hash + ".out")
+	errOut, ok2 := pather.DiskPath(hash + ".err")
+	return out, errOut, ok1 && ok2
+}
+
+// openCapture creates (or truncates) the temporary file path+".tmp" to
+// capture a stream into, alongside the stream still being written through
+// to the terminal. On error the caller falls back to the original stream.
+func openCapture(path string) (*os.File, error) {
+	return os.OpenFile(path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+}
+
+// finalizeCapture promotes the temporary capture files to their
+// permanent names if their combined size fits within maxSize, otherwise
+// discards them (and any previously cached output for this hash).
+func finalizeCapture(outPath, errPath string, maxSize int64) (hasOutput bool) {
+	var total int64
+	for _, p := range []string{outPath, errPath} {
+		info, err := os.Stat(p + ".tmp")
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+	}
+	if total > maxSize {
+		discardCapture(outPath, errPath)
+		os.Remove(outPath)
+		os.Remove(errPath)
+		return false
+	}
+	renamed := false
+	for _, p := range []string{outPath, errPath} {
+		if _, err := os.Stat(p + ".tmp"); err == nil {
+			if err := os.Rename(p+".tmp", p); err == nil {
+				renamed = true
+			}
+		}
+	}
+	return renamed
+}
+
+// discardCapture removes any in-progress capture files without promoting
+// them, used when a run couldn't be completed at all.
+func discardCapture(outPath, errPath string) {
+	os.Remove(outPath + ".tmp")
+	os.Remove(errPath + ".tmp")
+}
+
+func copyIfPresent(path string, dst *os.File) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	io.Copy(dst, file)
+}
+
+// evictToBudget removes the oldest cached marker+output sets (by marker
+// mtime) until the cache directory's total size is within budget. It's
+// called from Cleanup, after expired markers have already been removed.
+func evictToBudget(storage Storage, budget int64) {
+	entries, err := storage.ReadDir(".")
+	if err != nil {
+		return
+	}
+
+	type item struct {
+		hash  string
+		mtime int64
+		size  int64
+	}
+	var items []item
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || !isMarkerName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		size := info.Size()
+		if outPath, errPath, ok := outputPaths(storage, entry.Name()); ok {
+			size += fileSize(outPath) + fileSize(errPath)
+		}
+		total += size
+		items = append(items, item{hash: entry.Name(), mtime: info.ModTime().UnixNano(), size: size})
+	}
+	if total <= budget {
+		return
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].mtime < items[j].mtime })
+	for _, it := range items {
+		if total <= budget {
+			break
+		}
+		if err := storage.Remove(it.hash); err != nil {
+			continue
+		}
+		if outPath, errPath, ok := outputPaths(storage, it.hash); ok {
+			os.Remove(outPath)
+			os.Remove(errPath)
+		}
+		if pather, ok := storage.(diskPather); ok {
+			if lockPath, ok := pather.DiskPath(it.hash + ".lock"); ok {
+				os.Remove(lockPath)
+			}
+		}
+		total -= it.size
+	}
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// sizeSuffixes is ordered longest-suffix-first so that e.g. "MB" is
+// matched before the shorter "B" would otherwise shadow it.
+var sizeSuffixes = []struct {
+	suffix string
+	factor int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses human-friendly byte sizes such as "512K", "100MB" or a
+// plain byte count, for use when building Options from user input.
+func ParseSize(raw string) (int64, error) {
+	raw = strings.TrimSpace(raw)
+	multiplier := int64(1)
+	upper := strings.ToUpper(raw)
+	for _, s := range sizeSuffixes {
+		if strings.HasSuffix(upper, s.suffix) {
+			raw = raw[:len(raw)-len(s.suffix)]
+			multiplier = s.factor
+			break
+		}
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", raw, err)
+	}
+	return value * multiplier, nil
+}
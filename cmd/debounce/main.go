@@ -0,0 +1,196 @@
+// Command debounce runs another command only if enough time has passed
+// since it last ran (and, with the right flags, since its inputs last
+// changed); otherwise it reports the previous run's result. See the
+// debounce package for the underlying library.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nicois/debounce"
+)
+
+func main() {
+	os.Exit(run(os.Args))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet(progname(args), flag.ContinueOnError)
+	wait := fs.Bool("wait", false, "if another invocation is currently running the debounced command, block until it finishes instead of exiting immediately")
+	replay := fs.String("replay", "on-success", "whether to replay the last run's captured stdout/stderr when skipping a run: always|on-success|never")
+	maxOutputSize := sizeFlag(0)
+	fs.Var(&maxOutputSize, "max-output-size", "largest combined stdout+stderr that will be cached for replay (e.g. 512K, 10MB); defaults to 1MB")
+	cacheBudget := sizeFlag(0)
+	fs.Var(&cacheBudget, "cache-budget", "total cached output size cleanup will try to keep the cache directory under (e.g. 100MB); defaults to 100MB")
+	var inputFiles, inputGlobs, inputEnvs multiFlag
+	fs.Var(&inputFiles, "input-file", "path to a file whose content contributes to the debounce hash (repeatable)")
+	fs.Var(&inputGlobs, "input-glob", "glob pattern expanding to files whose content contributes to the debounce hash (repeatable)")
+	fs.Var(&inputEnvs, "input-env", "name of an environment variable whose value contributes to the debounce hash (repeatable)")
+	inputStdin := fs.Bool("input-stdin", false, "read stdin fully, fold its content into the debounce hash, and forward it to the command")
+	logFile := fs.String("log-file", os.Getenv("DEBOUNCE_LOG"), "path to append structured lifecycle event logs to; also settable via the DEBOUNCE_LOG env var")
+	logFormat := fs.String("log-format", "json", "format of records written to --log-file: text|json")
+	maxInterval := fs.Duration("max-interval", 0, "force a run once it's been this long since the last one, even if still within the cooldown or a failure backoff delay")
+	failureBackoff := fs.String("only-on-failure-backoff", "", "comma-separated durations (e.g. 1m,5m,30m) to wait after consecutive failures, in place of the normal cooldown, holding at the last value for further failures")
+	jitter := fs.Duration("jitter", 0, "add a uniform random delay in [0, jitter) on top of the effective cooldown, to de-synchronise fleet-wide crons")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return 1
+	}
+
+	backoff, err := parseDurationList(*failureBackoff)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "debounce: --only-on-failure-backoff: %v\n", err)
+		return 1
+	}
+
+	logger, closeLogger, err := openLogger(*logFile, *logFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "debounce: %v\n", err)
+		return 1
+	}
+	defer closeLogger()
+
+	rest := fs.Args()
+	if len(rest) < 2 {
+		fmt.Fprintln(os.Stderr, "debounce: not enough arguments (first provide the cooldown value, followed by the command and its arguments)")
+		return 1
+	}
+	cooldown, err := time.ParseDuration(rest[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "debounce: %v\n", err)
+		return 1
+	}
+
+	d, err := debounce.New(debounce.Options{
+		MinInterval:    cooldown,
+		MaxInterval:    *maxInterval,
+		FailureBackoff: backoff,
+		Jitter:         *jitter,
+		Wait:           *wait,
+		Replay:         *replay,
+		MaxOutputSize:  int64(maxOutputSize),
+		CacheBudget:    int64(cacheBudget),
+		Logger:         logger,
+		Inputs: debounce.InputSpec{
+			Files:        inputFiles,
+			Globs:        inputGlobs,
+			EnvVars:      inputEnvs,
+			IncludeStdin: *inputStdin,
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "debounce: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	result, err := d.Run(ctx, rest[1:])
+	if err != nil {
+		if errors.Is(err, debounce.ErrLocked) {
+			fmt.Fprintln(os.Stderr, "debounce: another invocation is already running this command, exiting")
+			return debounce.ExitLocked
+		}
+		fmt.Fprintf(os.Stderr, "debounce: %v\n", err)
+		return 1
+	}
+	return result.ExitCode
+}
+
+// openLogger builds the slog.Logger used for structured lifecycle event
+// records. With no log file configured, it discards everything; the
+// returned close func flushes and closes whatever file was opened.
+func openLogger(path, format string) (*slog.Logger, func(), error) {
+	if path == "" {
+		return slog.New(slog.NewJSONHandler(io.Discard, nil)), func() {}, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open --log-file %q: %w", path, err)
+	}
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(file, nil)
+	case "json":
+		handler = slog.NewJSONHandler(file, nil)
+	default:
+		file.Close()
+		return nil, nil, fmt.Errorf("invalid --log-format %q: must be text or json", format)
+	}
+	return slog.New(handler), func() { file.Close() }, nil
+}
+
+// parseDurationList parses a comma-separated list of durations, such as
+// "1m,5m,30m", used for --only-on-failure-backoff. An empty string
+// yields a nil (disabled) list.
+func parseDurationList(raw string) ([]time.Duration, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	parts := strings.Split(raw, ",")
+	durations := make([]time.Duration, len(parts))
+	for i, part := range parts {
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		durations[i] = d
+	}
+	return durations, nil
+}
+
+func progname(args []string) string {
+	if len(args) == 0 {
+		return "debounce"
+	}
+	return filepath.Base(args[0])
+}
+
+// sizeFlag adapts debounce.ParseSize to flag.Value, for human-friendly
+// byte sizes such as "512K" or "100MB".
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d", int64(*s))
+}
+
+func (s *sizeFlag) Set(raw string) error {
+	n, err := debounce.ParseSize(raw)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(n)
+	return nil
+}
+
+// multiFlag collects repeated occurrences of a flag (e.g. --input-file a
+// --input-file b) into a slice, implementing flag.Value.
+type multiFlag []string
+
+func (m *multiFlag) String() string {
+	if m == nil {
+		return ""
+	}
+	return strings.Join(*m, ",")
+}
+
+func (m *multiFlag) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
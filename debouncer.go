@@ -0,0 +1,363 @@
+package debounce
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Debouncer decides, per invocation of a command, whether enough time (or
+// input change) has passed since its last run to run it again, and
+// otherwise reports (and optionally replays) that run's result.
+type Debouncer struct {
+	opts Options
+}
+
+// New constructs a Debouncer from opts, filling in defaults for anything
+// left zero.
+func New(opts Options) (*Debouncer, error) {
+	opts = opts.withDefaults()
+	if opts.MinInterval <= 0 {
+		return nil, fmt.Errorf("debounce: MinInterval must be positive")
+	}
+	switch opts.Replay {
+	case "always", "on-success", "never":
+	default:
+		return nil, fmt.Errorf("debounce: invalid Replay %q: must be always, on-success or never", opts.Replay)
+	}
+	return &Debouncer{opts: opts}, nil
+}
+
+// Result describes the outcome of a Run call.
+type Result struct {
+	// Ran is true if the command was actually executed; false if Run
+	// instead reported (and possibly replayed) a previous run.
+	Ran bool
+	// Replayed is true if a previous run's captured output was
+	// reproduced on stdout/stderr.
+	Replayed bool
+	// ExitCode is the command's exit code, whether from this run or a
+	// replayed one.
+	ExitCode int
+	// Duration is how long the command took to run; zero if it wasn't
+	// run this time.
+	Duration time.Duration
+}
+
+func (d *Debouncer) hash(cmd []string, stdin []byte) (string, []string, error) {
+	hasher := d.opts.NewHasher()
+	resolved := cmd[0]
+	if p, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = p
+	}
+	resolved, err := exec.LookPath(resolved)
+	if err != nil {
+		return "", nil, err
+	}
+	absolutePath, err := filepath.Abs(resolved)
+	if err != nil {
+		return "", nil, fmt.Errorf("%q could not be resolved to an absolute path", cmd[0])
+	}
+	hasher.Write([]byte(absolutePath))
+	for _, arg := range cmd[1:] {
+		hasher.Write([]byte(arg))
+	}
+	contributing, err := hashInputs(hasher, d.opts.Inputs, stdin)
+	if err != nil {
+		return "", nil, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), contributing, nil
+}
+
+// Runnable reports whether cmd is due to run, per the configured
+// scheduling policy (MinInterval, MaxInterval, FailureBackoff, Jitter),
+// along with the time of its last recorded run (zero if none). It
+// performs no locking and has no side effects.
+func (d *Debouncer) Runnable(cmd []string) (bool, time.Time) {
+	hash, _, err := d.hash(cmd, nil)
+	if err != nil {
+		return true, time.Time{}
+	}
+	marker, lastRun := readMarker(d.opts.Storage, hash)
+	return d.runnable(marker, lastRun, d.effectiveCooldown(marker)), lastRun
+}
+
+// runnable evaluates the scheduling policy against the time of the last
+// run and a cooldown already computed by effectiveCooldown (which is where
+// marker's history comes in; marker itself is not read here). cooldown is taken as a parameter, rather than
+// recomputed here, so that a single jittered value decides the outcome
+// and is reused in whatever log line reports it.
+func (d *Debouncer) runnable(marker *Marker, lastRun time.Time, cooldown time.Duration) bool {
+	if lastRun.IsZero() {
+		return true
+	}
+	since := d.opts.Clock().Sub(lastRun)
+	if d.opts.MaxInterval > 0 && since > d.opts.MaxInterval {
+		return true
+	}
+	return since > cooldown
+}
+
+// effectiveCooldown is MinInterval, unless the last run(s) failed and
+// FailureBackoff is configured, in which case it's the backoff delay for
+// the current consecutive-failure streak. Jitter, if set, is added on
+// top either way.
+func (d *Debouncer) effectiveCooldown(marker *Marker) time.Duration {
+	cooldown := d.opts.MinInterval
+	if len(d.opts.FailureBackoff) > 0 && marker != nil {
+		if streak := consecutiveFailures(marker.History); streak > 0 {
+			index := streak - 1
+			if index >= len(d.opts.FailureBackoff) {
+				index = len(d.opts.FailureBackoff) - 1
+			}
+			cooldown = d.opts.FailureBackoff[index]
+		}
+	}
+	if d.opts.Jitter > 0 {
+		cooldown += time.Duration(rand.Int64N(int64(d.opts.Jitter)))
+	}
+	return cooldown
+}
+
+// consecutiveFailures counts the non-zero-exit-code entries at the tail
+// of history, stopping at the first success.
+func consecutiveFailures(history []HistoryEntry) int {
+	count := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].ExitCode == 0 {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// Run executes cmd if its cooldown has elapsed, or otherwise returns (and,
+// per the Replay policy, reproduces) its last recorded result. If another
+// invocation already holds the lock for this command and Options.Wait is
+// false, Run returns ErrLocked immediately. ctx governs the command's
+// lifetime via exec.CommandContext: cancelling it terminates the command.
+func (d *Debouncer) Run(ctx context.Context, cmd []string) (Result, error) {
+	var stdin []byte
+	if d.opts.Inputs.IncludeStdin {
+		var err error
+		if stdin, err = io.ReadAll(os.Stdin); err != nil {
+			return Result{}, fmt.Errorf("debounce: could not read stdin: %w", err)
+		}
+	}
+
+	hash, inputs, err := d.hash(cmd, stdin)
+	if err != nil {
+		return Result{}, err
+	}
+
+	l, err := acquireLock(d.opts.Storage, hash, d.opts.Wait)
+	if err != nil {
+		return Result{}, err
+	}
+	defer l.release()
+
+	marker, lastRun := readMarker(d.opts.Storage, hash)
+	cooldown := d.effectiveCooldown(marker)
+	command := strings.Join(cmd, " ")
+	sinceLastRun := d.opts.Clock().Sub(lastRun)
+	d.opts.Logger.Info("evaluated",
+		"hash", hash, "command", command,
+		"cooldown_ms", cooldown.Milliseconds(),
+		"since_last_run_ms", sinceLastRun.Milliseconds(),
+		"pid", os.Getpid())
+
+	if !d.runnable(marker, lastRun, cooldown) {
+		result := d.replay(hash, marker)
+		d.opts.Logger.Info("skipped_cooldown",
+			"hash", hash, "command", command,
+			"cooldown_ms", cooldown.Milliseconds(),
+			"since_last_run_ms", sinceLastRun.Milliseconds(),
+			"exit_code", result.ExitCode,
+			"pid", os.Getpid())
+		return result, nil
+	}
+
+	if rand.Float32() < 0.01 {
+		if err := d.Cleanup(ctx); err != nil {
+			d.opts.Logger.Warn("cleanup failed", "error", err.Error())
+		}
+	}
+
+	return d.execute(ctx, cmd, hash, inputs, stdin, marker)
+}
+
+func (d *Debouncer) execute(ctx context.Context, cmdArgs []string, hash string, inputs []string, stdin []byte, previous *Marker) (Result, error) {
+	start := d.opts.Clock()
+	command := strings.Join(cmdArgs, " ")
+	d.opts.Logger.Info("started", "hash", hash, "command", command, "pid", os.Getpid())
+
+	cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	outPath, errPath, capturable := outputPaths(d.opts.Storage, hash)
+
+	cmd.Stdout = os.Stdout
+	if capturable {
+		if f, err := openCapture(outPath); err == nil {
+			defer f.Close()
+			cmd.Stdout = io.MultiWriter(os.Stdout, f)
+		}
+	}
+	cmd.Stderr = os.Stderr
+	if capturable {
+		if f, err := openCapture(errPath); err == nil {
+			defer f.Close()
+			cmd.Stderr = io.MultiWriter(os.Stderr, f)
+		}
+	}
+
+	runErr := cmd.Run()
+	exitCode := 0
+	if runErr != nil {
+		if ee, ok := runErr.(*exec.ExitError); ok {
+			exitCode = ee.ExitCode()
+		} else {
+			if capturable {
+				discardCapture(outPath, errPath)
+			}
+			duration := d.opts.Clock().Sub(start)
+			d.opts.Logger.Error("finished",
+				"hash", hash, "command", command, "exit_code", 1,
+				"duration_ms", duration.Milliseconds(), "error", runErr.Error(), "pid", os.Getpid())
+			return Result{Ran: true, ExitCode: 1, Duration: duration}, nil
+		}
+	}
+
+	// A failing run only starts a cooldown if FailureBackoff or MaxInterval
+	// is configured: those are what give a persisted failure meaning (a
+	// backoff delay to honour, or a ceiling to bound it by). Otherwise, a
+	// plain flat-cooldown debounce keeps its original behaviour of
+	// retrying a failing command on every invocation.
+	persist := exitCode == 0 || len(d.opts.FailureBackoff) > 0 || d.opts.MaxInterval > 0
+
+	// Output is only kept for a run whose marker is written, so cached
+	// output always belongs to the recorded run.
+	hasOutput := false
+	if capturable {
+		if persist {
+			hasOutput = finalizeCapture(outPath, errPath, d.opts.MaxOutputSize)
+		} else {
+			discardCapture(outPath, errPath)
+		}
+	}
+
+	if persist {
+		finishedAt := d.opts.Clock()
+		marker := &Marker{
+			Command:   cmdArgs,
+			ExitCode:  exitCode,
+			HasOutput: hasOutput,
+			Inputs:    inputs,
+			History:   appendHistory(previous, HistoryEntry{Time: finishedAt, ExitCode: exitCode}),
+		}
+		if err := writeMarker(d.opts.Storage, hash, marker); err != nil {
+			d.opts.Logger.Warn("could not persist marker", "hash", hash, "error", err.Error())
+		}
+	}
+
+	duration := d.opts.Clock().Sub(start)
+	d.opts.Logger.Info("finished",
+		"hash", hash, "command", command, "exit_code", exitCode,
+		"duration_ms", duration.Milliseconds(), "pid", os.Getpid())
+	return Result{Ran: true, ExitCode: exitCode, Duration: duration}, nil
+}
+
+func (d *Debouncer) replay(hash string, marker *Marker) Result {
+	if marker == nil {
+		return Result{}
+	}
+	replayed := false
+	switch d.opts.Replay {
+	case "never":
+	case "always":
+		if marker.HasOutput {
+			replayed = d.emitCachedOutput(hash)
+		}
+	default: // "on-success"
+		if marker.ExitCode == 0 && marker.HasOutput {
+			replayed = d.emitCachedOutput(hash)
+		}
+	}
+	return Result{ExitCode: marker.ExitCode, Replayed: replayed}
+}
+
+func (d *Debouncer) emitCachedOutput(hash string) bool {
+	outPath, errPath, capturable := outputPaths(d.opts.Storage, hash)
+	if !capturable {
+		return false
+	}
+	copyIfPresent(outPath, os.Stdout)
+	copyIfPresent(errPath, os.Stderr)
+	return true
+}
+
+// Cleanup removes markers (and their cached output) whose effective
+// cooldown has expired, then evicts the oldest remaining cached output
+// until the total is within Options.CacheBudget.
+func (d *Debouncer) Cleanup(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	entries, err := d.opts.Storage.ReadDir(".")
+	if err != nil {
+		return fmt.Errorf("debounce: cleanup could not read storage: %w", err)
+	}
+	if len(entries) < 20 {
+		// there's no point in cleaning up with so few entries
+		return nil
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isMarkerName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		content, err := fs.ReadFile(d.opts.Storage, entry.Name())
+		if err != nil {
+			continue
+		}
+		var marker Marker
+		if err := json.Unmarshal(content, &marker); err != nil {
+			continue
+		}
+		// Expiry must be judged against the effective cooldown, not a flat
+		// MinInterval: a marker recording an ongoing failure streak under
+		// FailureBackoff is due to fire much later than MinInterval, and
+		// removing it early would silently reset the streak.
+		if d.opts.Clock().Sub(info.ModTime()) > d.effectiveCooldown(&marker) {
+			if err := d.opts.Storage.Remove(entry.Name()); err != nil {
+				continue
+			}
+			if outPath, errPath, ok := outputPaths(d.opts.Storage, entry.Name()); ok {
+				os.Remove(outPath)
+				os.Remove(errPath)
+			}
+			d.opts.Logger.Info("cleanup_removed",
+				"hash", entry.Name(), "command", strings.Join(marker.Command, " "), "pid", os.Getpid())
+		}
+	}
+
+	evictToBudget(d.opts.Storage, d.opts.CacheBudget)
+	return nil
+}
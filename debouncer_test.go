@@ -0,0 +1,345 @@
+package debounce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStorage is an in-memory Storage for tests, standing in for the
+// on-disk ConfigPath scheme. It deliberately doesn't implement
+// diskPather, so locking falls back to acquireProcessLock and output
+// capture is skipped, per their documented fallback behaviour.
+type memStorage struct {
+	mu    sync.Mutex
+	files map[string]*memEntry
+	now   func() time.Time
+}
+
+type memEntry struct {
+	data    []byte
+	modTime time.Time
+}
+
+// newMemStorage builds a memStorage that stamps writes with now, mirroring
+// how a real on-disk file's mtime would track the system clock: readMarker
+// derives lastRun from that mtime, not from Options.Clock directly, so
+// tests need the two to agree.
+func newMemStorage(now func() time.Time) *memStorage {
+	return &memStorage{files: make(map[string]*memEntry), now: now}
+}
+
+type memFile struct {
+	*bytes.Reader
+	info memFileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() fs.FileMode  { return 0600 }
+func (i memFileInfo) ModTime() time.Time { return i.modTime }
+func (i memFileInfo) IsDir() bool        { return false }
+func (i memFileInfo) Sys() any           { return nil }
+
+func (s *memStorage) Open(name string) (fs.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return &memFile{
+		Reader: bytes.NewReader(entry.data),
+		info:   memFileInfo{name: name, size: int64(len(entry.data)), modTime: entry.modTime},
+	}, nil
+}
+
+func (s *memStorage) Stat(name string) (fs.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return memFileInfo{name: name, size: int64(len(entry.data)), modTime: entry.modTime}, nil
+}
+
+func (s *memStorage) ReadDir(name string) ([]fs.DirEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []fs.DirEntry
+	for n, entry := range s.files {
+		entries = append(entries, fs.FileInfoToDirEntry(memFileInfo{name: n, size: int64(len(entry.data)), modTime: entry.modTime}))
+	}
+	return entries, nil
+}
+
+func (s *memStorage) WriteFile(name string, data []byte, _ fs.FileMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = &memEntry{data: append([]byte{}, data...), modTime: s.now()}
+	return nil
+}
+
+func (s *memStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, name)
+	return nil
+}
+
+func (s *memStorage) MkdirAll(string, fs.FileMode) error { return nil }
+
+// fakeClock lets tests control the passage of time deterministically.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(start time.Time) *fakeClock {
+	return &fakeClock{now: start}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestDebouncer(t *testing.T, clock *fakeClock, opts Options) *Debouncer {
+	t.Helper()
+	opts.Storage = newMemStorage(clock.Now)
+	opts.Clock = clock.Now
+	d, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return d
+}
+
+func TestDebouncer_RunsFirstTimeThenSkipsWithinCooldown(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	d := newTestDebouncer(t, clock, Options{MinInterval: time.Minute})
+
+	result, err := d.Run(context.Background(), []string{"true"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !result.Ran {
+		t.Fatalf("first invocation: want Ran=true, got %+v", result)
+	}
+
+	result, err = d.Run(context.Background(), []string{"true"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Ran {
+		t.Fatalf("within cooldown: want Ran=false, got %+v", result)
+	}
+}
+
+func TestDebouncer_RunsAgainAfterCooldownElapses(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	d := newTestDebouncer(t, clock, Options{MinInterval: time.Minute})
+
+	if _, err := d.Run(context.Background(), []string{"true"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+
+	result, err := d.Run(context.Background(), []string{"true"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !result.Ran {
+		t.Fatalf("after cooldown: want Ran=true, got %+v", result)
+	}
+}
+
+func TestDebouncer_MaxIntervalForcesRunDespiteCooldown(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	d := newTestDebouncer(t, clock, Options{
+		MinInterval: time.Hour,
+		MaxInterval: time.Minute,
+	})
+
+	if _, err := d.Run(context.Background(), []string{"true"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+
+	result, err := d.Run(context.Background(), []string{"true"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !result.Ran {
+		t.Fatalf("want MaxInterval to force a run, got %+v", result)
+	}
+}
+
+func TestDebouncer_FailureBackoffOverridesMinInterval(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	d := newTestDebouncer(t, clock, Options{
+		MinInterval:    time.Second,
+		FailureBackoff: []time.Duration{time.Hour},
+	})
+
+	result, err := d.Run(context.Background(), []string{"false"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !result.Ran || result.ExitCode == 0 {
+		t.Fatalf("want a failing first run, got %+v", result)
+	}
+
+	// Long past MinInterval, but well within the failure backoff delay.
+	clock.Advance(10 * time.Second)
+	runnable, _ := d.Runnable([]string{"false"})
+	if runnable {
+		t.Fatalf("want runnable=false during failure backoff, got true")
+	}
+
+	clock.Advance(2 * time.Hour)
+	runnable, _ = d.Runnable([]string{"false"})
+	if !runnable {
+		t.Fatalf("want runnable=true once failure backoff has elapsed, got false")
+	}
+}
+
+func TestDebouncer_EffectiveCooldownVariesAcrossSeparateCalls(t *testing.T) {
+	marker := &Marker{}
+	d := &Debouncer{opts: Options{MinInterval: time.Second, Jitter: time.Hour}.withDefaults()}
+
+	cooldown := d.effectiveCooldown(marker)
+	for i := 0; i < 100; i++ {
+		if got := d.effectiveCooldown(marker); got != cooldown {
+			return
+		}
+	}
+	t.Fatalf("expected effectiveCooldown to vary across separate calls (jitter looked frozen at %v)", cooldown)
+}
+
+func TestDebouncer_RunnableReflectsLastRecordedRun(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	d := newTestDebouncer(t, clock, Options{MinInterval: time.Minute})
+
+	runnable, lastRun := d.Runnable([]string{"true"})
+	if !runnable || !lastRun.IsZero() {
+		t.Fatalf("before any run: want (true, zero), got (%v, %v)", runnable, lastRun)
+	}
+
+	if _, err := d.Run(context.Background(), []string{"true"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	runnable, lastRun = d.Runnable([]string{"true"})
+	if runnable || lastRun.IsZero() {
+		t.Fatalf("after run, within cooldown: want (false, non-zero), got (%v, %v)", runnable, lastRun)
+	}
+}
+
+func TestDebouncer_FailingRunWithoutBackoffRetriesEveryInvocation(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	d := newTestDebouncer(t, clock, Options{MinInterval: time.Hour})
+
+	result, err := d.Run(context.Background(), []string{"false"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !result.Ran || result.ExitCode == 0 {
+		t.Fatalf("want a failing first run, got %+v", result)
+	}
+
+	// No FailureBackoff or MaxInterval is configured, so a failing run
+	// shouldn't start a cooldown: the very next invocation, with no time
+	// elapsed, should retry rather than being debounced.
+	result, err = d.Run(context.Background(), []string{"false"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !result.Ran {
+		t.Fatalf("want a plain flat-cooldown failure to retry immediately, got %+v", result)
+	}
+}
+
+func TestDebouncer_CleanupRespectsFailureBackoff(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	storage := newMemStorage(clock.Now)
+	d, err := New(Options{
+		MinInterval:    time.Second,
+		FailureBackoff: []time.Duration{time.Hour},
+		Storage:        storage,
+		Clock:          clock.Now,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	failingHash := strings.Repeat("d", 64)
+	failing, err := json.Marshal(&Marker{
+		ExitCode: 1,
+		History:  []HistoryEntry{{Time: clock.Now(), ExitCode: 1}},
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if err := storage.WriteFile(failingHash, failing, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// padWithFreshEntries (re)writes enough just-created, not-yet-expired
+	// markers that Cleanup's "too few entries to bother" guard never
+	// short-circuits the pass, without those entries themselves expiring
+	// and tripping the guard back below 20 before the next call.
+	padWithFreshEntries := func() {
+		for i := 0; i < 20; i++ {
+			content, err := json.Marshal(&Marker{})
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if err := storage.WriteFile(fmt.Sprintf("%064x", i), content, 0600); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+		}
+	}
+
+	// Well past MinInterval, but nowhere near the failure backoff delay.
+	padWithFreshEntries()
+	clock.Advance(10 * time.Minute)
+	if err := d.Cleanup(context.Background()); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if _, err := storage.Stat(failingHash); err != nil {
+		t.Fatalf("want the failing marker to survive cleanup during its backoff delay, got: %v", err)
+	}
+
+	padWithFreshEntries()
+	clock.Advance(2 * time.Hour)
+	if err := d.Cleanup(context.Background()); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if _, err := storage.Stat(failingHash); err == nil {
+		t.Fatalf("want the failing marker removed once its backoff delay has elapsed")
+	}
+}
@@ -0,0 +1,74 @@
+package debounce
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// InputSpec describes the extra, non-argv inputs that should contribute
+// to a command's debounce hash: file contents, glob-matched files,
+// environment variables, and stdin.
+type InputSpec struct {
+	Files        []string
+	Globs        []string
+	EnvVars      []string
+	IncludeStdin bool
+}
+
+// expandFiles resolves Files and Globs into a single, deterministically
+// ordered list of paths to hash: the literal files in the order given,
+// followed by each glob's matches sorted lexically.
+func (s InputSpec) expandFiles() ([]string, error) {
+	result := append([]string{}, s.Files...)
+	for _, pattern := range s.Globs {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input glob %q: %w", pattern, err)
+		}
+		sort.Strings(matches)
+		result = append(result, matches...)
+	}
+	return result, nil
+}
+
+// hashInputs folds every configured input into hasher, returning a
+// human-readable description of each one. That description is persisted
+// in the marker's Inputs field, as a record of what went into the hash.
+func hashInputs(hasher io.Writer, spec InputSpec, stdin []byte) ([]string, error) {
+	var contributing []string
+
+	files, err := spec.expandFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("input file %q: %w", path, err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("input file %q: %w", path, err)
+		}
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(hasher, "file:%s:%d:%d:%x", path, info.Size(), info.ModTime().UnixNano(), sum)
+		contributing = append(contributing, fmt.Sprintf("file:%s size=%d mtime=%d sha256=%x", path, info.Size(), info.ModTime().UnixNano(), sum))
+	}
+
+	for _, name := range spec.EnvVars {
+		value := os.Getenv(name)
+		fmt.Fprintf(hasher, "env:%s=%s", name, value)
+		contributing = append(contributing, fmt.Sprintf("env:%s", name))
+	}
+
+	if spec.IncludeStdin {
+		hasher.Write(stdin)
+		contributing = append(contributing, fmt.Sprintf("stdin:size=%d sha256=%x", len(stdin), sha256.Sum256(stdin)))
+	}
+
+	return contributing, nil
+}
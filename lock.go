@@ -0,0 +1,78 @@
+package debounce
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// ExitLocked is the conventional process exit code a cmd/debounce-style
+// CLI should use when it loses the race for a debounce slot and wasn't
+// asked to wait for the winner.
+const ExitLocked = 75 // EX_TEMPFAIL, borrowed from sysexits.h
+
+// ErrLocked is returned by Run when another invocation already holds the
+// lock for this command and Options.Wait is false.
+var ErrLocked = errors.New("debounce: another invocation is already running this command")
+
+// lock is held across the runnability check, the run itself, and the
+// marker write, so that two racing invocations can never both decide
+// they're runnable.
+type lock struct {
+	release func()
+}
+
+// processLocks backs acquireLock for Storage backends that aren't
+// actually files on disk (so flock doesn't apply); it only provides
+// mutual exclusion within a single process, which is sufficient for
+// such backends since they're not shared across processes anyway.
+var processLocks sync.Map // map[string]*sync.Mutex
+
+func acquireLock(storage Storage, hash string, wait bool) (*lock, error) {
+	if pather, ok := storage.(diskPather); ok {
+		if path, ok := pather.DiskPath(hash + ".lock"); ok {
+			return acquireFileLock(path, wait)
+		}
+	}
+	return acquireProcessLock(hash, wait)
+}
+
+func acquireFileLock(path string, wait bool) (*lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	flags := syscall.LOCK_EX
+	if !wait {
+		flags |= syscall.LOCK_NB
+	}
+	if err := syscall.Flock(int(file.Fd()), flags); err != nil {
+		file.Close()
+		if !wait && errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	return &lock{release: func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}}, nil
+}
+
+func acquireProcessLock(hash string, wait bool) (*lock, error) {
+	value, _ := processLocks.LoadOrStore(hash, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	if wait {
+		mu.Lock()
+		return &lock{release: mu.Unlock}, nil
+	}
+	if !mu.TryLock() {
+		return nil, ErrLocked
+	}
+	return &lock{release: mu.Unlock}, nil
+}
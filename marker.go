@@ -0,0 +1,82 @@
+package debounce
+
+import (
+	"encoding/json"
+	"io/fs"
+	"regexp"
+	"time"
+)
+
+// Marker is the JSON record persisted per debounce hash, capturing enough
+// about the last run to decide future runnability and to support
+// replaying its result.
+type Marker struct {
+	Command   []string       `json:"command"`
+	ExitCode  int            `json:"exit_code"`
+	HasOutput bool           `json:"has_output"`
+	Inputs    []string       `json:"inputs,omitempty"`
+	History   []HistoryEntry `json:"history,omitempty"`
+}
+
+// HistoryEntry records one past run, oldest first, for scheduling
+// policies (such as failure backoff) that need more than just the most
+// recent result.
+type HistoryEntry struct {
+	Time     time.Time `json:"time"`
+	ExitCode int       `json:"exit_code"`
+}
+
+// maxHistoryEntries bounds the marker's history to a small ring buffer
+// rather than letting it grow unboundedly across a command's lifetime.
+const maxHistoryEntries = 10
+
+// appendHistory returns previous's history (if any) with entry appended,
+// trimmed to the most recent maxHistoryEntries.
+func appendHistory(previous *Marker, entry HistoryEntry) []HistoryEntry {
+	var history []HistoryEntry
+	if previous != nil {
+		history = previous.History
+	}
+	history = append(history, entry)
+	if len(history) > maxHistoryEntries {
+		history = history[len(history)-maxHistoryEntries:]
+	}
+	return history
+}
+
+// hashNamePattern matches the plain marker filenames (hex sha256 digests)
+// as opposed to their associated ".lock"/".out"/".err" files.
+var hashNamePattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func isMarkerName(name string) bool {
+	return hashNamePattern.MatchString(name)
+}
+
+// readMarker loads the previously persisted marker for hash, if any,
+// along with the time of the last run (the marker file's mtime).
+func readMarker(storage Storage, hash string) (marker *Marker, lastRun time.Time) {
+	info, err := storage.Stat(hash)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	content, err := fs.ReadFile(storage, hash)
+	if err != nil {
+		return nil, info.ModTime()
+	}
+	marker = &Marker{}
+	if err := json.Unmarshal(content, marker); err != nil {
+		return nil, info.ModTime()
+	}
+	return marker, info.ModTime()
+}
+
+func writeMarker(storage Storage, hash string, marker *Marker) error {
+	content, err := json.Marshal(marker)
+	if err != nil {
+		return err
+	}
+	if err := storage.MkdirAll(".", 0700); err != nil {
+		return err
+	}
+	return storage.WriteFile(hash, content, 0600)
+}
@@ -0,0 +1,120 @@
+// Package debounce implements a memoizing wrapper around shell commands:
+// it runs a command only if enough time (or, with the right Options, a
+// change to its inputs) has passed since the last time it ran, and
+// otherwise can replay that run's captured result.
+package debounce
+
+import (
+	"crypto/sha256"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Options configures a Debouncer. Every field except MinInterval has a
+// usable zero-value default; set MinInterval, and only the others a
+// caller needs to override.
+type Options struct {
+	// MinInterval is how long must elapse since a command's last run
+	// before Run will execute it again. Required.
+	MinInterval time.Duration
+
+	// MaxInterval, if set, forces a run once it's been this long since
+	// the last one, even if still within MinInterval or a failure
+	// backoff delay. Useful so a cron entry never goes silent for too
+	// long regardless of other scheduling settings.
+	MaxInterval time.Duration
+
+	// FailureBackoff, if set, replaces MinInterval with an
+	// exponential-style backoff schedule once the last recorded run
+	// failed (non-zero exit code): the first consecutive failure waits
+	// FailureBackoff[0], the second FailureBackoff[1], and so on,
+	// holding at the last entry for any further consecutive failures.
+	// Runs that succeed return to MinInterval immediately.
+	FailureBackoff []time.Duration
+
+	// Jitter, if set, adds a uniform random delay in [0, Jitter) on top
+	// of the effective cooldown, to de-synchronise fleet-wide crons.
+	Jitter time.Duration
+
+	// Inputs folds extra content (files, globs, env vars, stdin) into the
+	// hash that identifies a command, beyond its argv.
+	Inputs InputSpec
+
+	// Wait controls what Run does when another invocation already holds
+	// the lock for this command: block until it releases (true) or
+	// return ErrLocked immediately (false, the default).
+	Wait bool
+
+	// Replay controls whether Run reproduces a skipped run's captured
+	// stdout/stderr: "always", "on-success" (the default) or "never".
+	Replay string
+
+	// MaxOutputSize is the largest combined stdout+stderr that will be
+	// cached for replay; beyond it, a run's output simply isn't cached.
+	// Defaults to 1MiB.
+	MaxOutputSize int64
+
+	// CacheBudget is the total cached output size Cleanup tries to keep
+	// the cache directory under, evicting the oldest entries first.
+	// Defaults to 100MiB.
+	CacheBudget int64
+
+	// ConfigPath is the directory markers, locks and cached output live
+	// under, when Storage isn't set explicitly. Defaults to
+	// ~/.config/debounce.
+	ConfigPath string
+
+	// Storage backs markers and cached output. Defaults to a DirStorage
+	// rooted at ConfigPath; substitute it in tests or for alternative
+	// backends.
+	Storage Storage
+
+	// Clock returns the current time. Defaults to time.Now; overridable
+	// so tests can control the passage of time.
+	Clock func() time.Time
+
+	// NewHasher constructs the hash used to derive a command's debounce
+	// key. Defaults to sha256.New.
+	NewHasher func() hash.Hash
+
+	// Logger receives one structured record per lifecycle event
+	// (evaluated, skipped_cooldown, started, finished, cleanup_removed).
+	// Defaults to a handler that discards everything.
+	Logger *slog.Logger
+}
+
+func (o Options) withDefaults() Options {
+	if o.ConfigPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			panic(err)
+		}
+		o.ConfigPath = filepath.Join(home, ".config", "debounce")
+	}
+	if o.Storage == nil {
+		o.Storage = DirStorage{Root: o.ConfigPath}
+	}
+	if o.Clock == nil {
+		o.Clock = time.Now
+	}
+	if o.NewHasher == nil {
+		o.NewHasher = sha256.New
+	}
+	if o.Logger == nil {
+		o.Logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+	}
+	if o.Replay == "" {
+		o.Replay = "on-success"
+	}
+	if o.MaxOutputSize == 0 {
+		o.MaxOutputSize = defaultMaxOutputSize
+	}
+	if o.CacheBudget == 0 {
+		o.CacheBudget = defaultCacheBudget
+	}
+	return o
+}
@@ -0,0 +1,54 @@
+package debounce
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Storage is the persistence backend for markers and cached output. It
+// extends fs.FS (read access) with the writes a Debouncer needs, so
+// alternative backends (in-memory, Redis, ...) can stand in for the
+// default on-disk scheme in tests.
+type Storage interface {
+	fs.FS
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Remove(name string) error
+	MkdirAll(name string, perm fs.FileMode) error
+}
+
+// diskPather is implemented by Storage backends that are actually backed
+// by real files, which is what flock-based locking and streamed output
+// capture need. Backends that don't implement it (e.g. an in-memory
+// Storage used in tests) still get correct marker semantics; they just
+// fall back to in-process locking and skip output capture.
+type diskPather interface {
+	DiskPath(name string) (string, bool)
+}
+
+// DirStorage is the default Storage, rooted at a directory on disk.
+type DirStorage struct {
+	Root string
+}
+
+func (d DirStorage) path(name string) string { return filepath.Join(d.Root, name) }
+
+func (d DirStorage) Open(name string) (fs.File, error) { return os.Open(d.path(name)) }
+
+func (d DirStorage) Stat(name string) (fs.FileInfo, error) { return os.Stat(d.path(name)) }
+
+func (d DirStorage) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(d.path(name)) }
+
+func (d DirStorage) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(d.path(name), data, perm)
+}
+
+func (d DirStorage) Remove(name string) error { return os.Remove(d.path(name)) }
+
+func (d DirStorage) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(d.path(name), perm)
+}
+
+func (d DirStorage) DiskPath(name string) (string, bool) { return d.path(name), true }